@@ -0,0 +1,150 @@
+// Command compresspdf compresses one or more pdf files in place, if they
+// don't appear to have been processed already.
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"runtime"
+
+	"github.com/urfave/cli"
+
+	"github.com/ginabythebay/compresspdf/pkg/compresspdf"
+)
+
+func main() {
+	app := cli.NewApp()
+	app.Name = "compresspdf"
+	app.Usage = "Compresses one or more pdf files in place, if they don't appear to have been processed already.  Requires that gs and pdfinfo are in the PATH"
+	app.UsageText = "compresspdf [global options] <pdf files> [pdf files...]"
+	app.HideVersion = true
+	app.Action = compressAll
+	app.Flags = []cli.Flag{
+		cli.BoolFlag{
+			Name:  "f, force",
+			Usage: "Attempts compression even if the file may have already been compressed",
+		},
+		cli.BoolFlag{
+			Name:  "q, quiet",
+			Usage: "No output unless there is an error",
+		},
+		cli.BoolFlag{
+			Name:  "v, verbose",
+			Usage: "Extra output",
+		},
+		cli.IntFlag{
+			Name:  "j, jobs",
+			Usage: "Number of pdfs to compress concurrently",
+			Value: runtime.NumCPU(),
+		},
+		cli.StringFlag{
+			Name:  "preset",
+			Usage: "gs PDFSETTINGS preset to use: screen, ebook, printer, prepress, or default",
+			Value: "screen",
+		},
+		cli.StringFlag{
+			Name:  "compat",
+			Usage: "gs CompatibilityLevel to target",
+			Value: "1.4",
+		},
+		cli.IntFlag{
+			Name:  "image-dpi",
+			Usage: "If set, downsample color/gray/mono images to this resolution",
+		},
+		cli.StringFlag{
+			Name:  "color-strategy",
+			Usage: "If set, gs ColorConversionStrategy to use: leaveColorUnchanged, Gray, RGB, or CMYK",
+		},
+		cli.IntFlag{
+			Name:  "jpeg-quality",
+			Usage: "If set (1-100), re-encode color images as JPEG at this quality",
+		},
+		cli.StringFlag{
+			Name:  "include",
+			Usage: "If set, only pdfs in a directory argument whose name matches this glob are compressed",
+		},
+		cli.StringFlag{
+			Name:  "exclude",
+			Usage: "If set, pdfs in a directory argument whose name matches this glob are skipped",
+		},
+		cli.BoolFlag{
+			Name:  "verify",
+			Usage: "Re-run pdfinfo on the compressed output and refuse to replace the original if its page count changed",
+		},
+		cli.StringFlag{
+			Name:  "backup-dir",
+			Usage: "If set, write each file's pre-replacement .bak copy here instead of alongside it",
+		},
+		cli.BoolFlag{
+			Name:  "keep-backup",
+			Usage: "Keep the .bak copy made before replacing each file instead of deleting it once verified",
+		},
+	}
+	err := app.Run(os.Args)
+	if err != nil {
+		fmt.Printf("%+v\n", err)
+		os.Exit(1)
+	}
+}
+
+func compressAll(c *cli.Context) error {
+	args := c.Args()
+	if len(args) == 0 {
+		return cli.NewExitError("You must specify the name of at least one pdf to compress", 1)
+	}
+
+	preset := c.String("preset")
+	if err := compresspdf.ValidatePreset(preset); err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+	colorStrategy := c.String("color-strategy")
+	if err := compresspdf.ValidateColorStrategy(colorStrategy); err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+	jpegQuality := c.Int("jpeg-quality")
+	if jpegQuality != 0 && (jpegQuality < 1 || jpegQuality > 100) {
+		return cli.NewExitError("--jpeg-quality must be between 1 and 100", 1)
+	}
+
+	quiet := c.Bool("quiet")
+	comp, err := compresspdf.New(
+		compresspdf.WithForce(c.Bool("force")),
+		compresspdf.WithVerbose(c.Bool("verbose")),
+		compresspdf.WithQuiet(quiet),
+		compresspdf.WithJobs(c.Int("jobs")),
+		compresspdf.WithPreset(preset),
+		compresspdf.WithCompat(c.String("compat")),
+		compresspdf.WithImageDPI(c.Int("image-dpi")),
+		compresspdf.WithColorStrategy(colorStrategy),
+		compresspdf.WithJpegQuality(jpegQuality),
+		compresspdf.WithInclude(c.String("include")),
+		compresspdf.WithExclude(c.String("exclude")),
+		compresspdf.WithVerify(c.Bool("verify")),
+		compresspdf.WithBackupDir(c.String("backup-dir")),
+		compresspdf.WithKeepBackup(c.Bool("keep-backup")),
+	)
+	if err != nil {
+		return err
+	}
+
+	results, err := comp.CompressAll(context.Background(), args)
+	if err != nil {
+		return err
+	}
+
+	if !quiet {
+		var cnt int
+		for _, res := range results {
+			if res.Skipped == "" {
+				cnt++
+			}
+		}
+		if cnt == 1 {
+			fmt.Printf("Compressed 1 file\n")
+		} else {
+			fmt.Printf("Compressed %d files\n", cnt)
+		}
+	}
+	return nil
+}
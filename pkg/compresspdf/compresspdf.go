@@ -0,0 +1,427 @@
+// Package compresspdf compresses pdf files in place using gs, skipping
+// files that appear to have already been processed unless forced.
+package compresspdf
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/ginabythebay/compresspdf/pool"
+)
+
+// Result reports the outcome of compressing a single pdf.
+type Result struct {
+	// Path is the on-disk pdf that was considered.  For archive and
+	// directory targets this is the extracted/discovered member, not the
+	// original command-line argument.
+	Path string
+
+	// OldSize and NewSize are the file's size before and after this run.
+	// If Skipped is non-empty, NewSize equals OldSize.
+	OldSize int64
+	NewSize int64
+
+	// Skipped explains why Path was left untouched, e.g. "already
+	// compressed" or "would have grown".  Empty means it was compressed.
+	Skipped string
+
+	// GSStderr is whatever gs wrote to its combined output.
+	GSStderr string
+}
+
+// Option configures a Compressor constructed by New.
+type Option func(*Compressor)
+
+// WithForce makes Compress attempt compression even when a pdf appears to
+// have already been processed by Ghostscript.
+func WithForce(force bool) Option {
+	return func(c *Compressor) { c.force = force }
+}
+
+// WithQuiet suppresses the "Compressed N files" style summary a caller
+// would otherwise want to print; it has no effect on Compress/CompressAll
+// themselves, whose only output is the progress detail enabled by
+// WithVerbose (written even when quiet is set), but is kept alongside the
+// other options so
+// cmd/compresspdf can thread all its flags through the same mechanism.
+func WithQuiet(quiet bool) Option {
+	return func(c *Compressor) { c.quiet = quiet }
+}
+
+// WithVerbose causes extra progress detail to be written to the
+// Compressor's logger (os.Stderr by default, see WithLogger).
+func WithVerbose(verbose bool) Option {
+	return func(c *Compressor) { c.verbose = verbose }
+}
+
+// WithLogger sets where verbose progress output is written.  Defaults to
+// os.Stderr.
+func WithLogger(w io.Writer) Option {
+	return func(c *Compressor) { c.logger = w }
+}
+
+// WithGhostscriptPath overrides the gs binary located on PATH by New.
+func WithGhostscriptPath(p string) Option {
+	return func(c *Compressor) { c.gs = p }
+}
+
+// WithPdfinfoPath overrides the pdfinfo binary located on PATH by New.
+func WithPdfinfoPath(p string) Option {
+	return func(c *Compressor) { c.pdfinfo = p }
+}
+
+// WithPreset sets the gs PDFSETTINGS preset: screen, ebook, printer,
+// prepress, or default.  Defaults to "screen".
+func WithPreset(preset string) Option {
+	return func(c *Compressor) { c.preset = preset }
+}
+
+// WithCompat sets the gs CompatibilityLevel.  Defaults to "1.4".
+func WithCompat(compat string) Option {
+	return func(c *Compressor) { c.compat = compat }
+}
+
+// WithImageDPI, if set, causes color/gray/mono images to be downsampled to
+// this resolution.
+func WithImageDPI(dpi int) Option {
+	return func(c *Compressor) { c.imageDPI = dpi }
+}
+
+// WithColorStrategy sets gs's ColorConversionStrategy: leaveColorUnchanged,
+// Gray, RGB, or CMYK.
+func WithColorStrategy(strategy string) Option {
+	return func(c *Compressor) { c.colorStrategy = strategy }
+}
+
+// WithJpegQuality, if set to a value between 1 and 100, re-encodes color
+// images as JPEG at that quality.
+func WithJpegQuality(quality int) Option {
+	return func(c *Compressor) { c.jpegQuality = quality }
+}
+
+// WithInclude restricts directory targets to pdfs whose base name matches
+// this glob.
+func WithInclude(glob string) Option {
+	return func(c *Compressor) { c.include = glob }
+}
+
+// WithExclude skips pdfs in directory targets whose base name matches this
+// glob.
+func WithExclude(glob string) Option {
+	return func(c *Compressor) { c.exclude = glob }
+}
+
+// WithJobs sets how many files CompressAll will compress concurrently.
+// Defaults to runtime.NumCPU().
+func WithJobs(jobs int) Option {
+	return func(c *Compressor) { c.jobs = jobs }
+}
+
+// WithWorkDir sets the directory used to stage gs output and extracted
+// archive members.  Defaults to a fresh directory under os.TempDir.
+func WithWorkDir(dir string) Option {
+	return func(c *Compressor) { c.workDir = dir }
+}
+
+// WithVerify makes compress re-run pdfinfo on the compressed output and
+// refuse to replace the original if the page counts don't match.
+func WithVerify(verify bool) Option {
+	return func(c *Compressor) { c.verify = verify }
+}
+
+// WithBackupDir sets the directory the pre-replacement .bak copy of each
+// file is written to.  Defaults to writing the .bak alongside the original.
+func WithBackupDir(dir string) Option {
+	return func(c *Compressor) { c.backupDir = dir }
+}
+
+// WithKeepBackup keeps the .bak copy made before replacing each file
+// instead of deleting it once the replacement is verified.
+func WithKeepBackup(keep bool) Option {
+	return func(c *Compressor) { c.keepBackup = keep }
+}
+
+// Compressor compresses pdfs in place using gs.
+type Compressor struct {
+	force   bool
+	quiet   bool
+	verbose bool
+	logger  io.Writer
+
+	gs      string
+	pdfinfo string
+
+	preset        string
+	compat        string
+	imageDPI      int
+	colorStrategy string
+	jpegQuality   int
+	include       string
+	exclude       string
+
+	jobs    int
+	workDir string
+
+	verify     bool
+	backupDir  string
+	keepBackup bool
+
+	outMu sync.Mutex
+}
+
+// New creates a Compressor, applying opts over sane defaults.  Unless
+// WithGhostscriptPath/WithPdfinfoPath are given, gs and pdfinfo are located
+// on PATH.
+func New(opts ...Option) (*Compressor, error) {
+	c := &Compressor{
+		logger: os.Stderr,
+		preset: "screen",
+		compat: "1.4",
+		jobs:   runtime.NumCPU(),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	if c.gs == "" {
+		p, err := exec.LookPath("gs")
+		if err != nil {
+			return nil, errors.Wrap(err, "Locating gs")
+		}
+		c.gs = p
+	}
+	if c.pdfinfo == "" {
+		p, err := exec.LookPath("pdfinfo")
+		if err != nil {
+			return nil, errors.Wrap(err, "Locating pdfinfo")
+		}
+		c.pdfinfo = p
+	}
+	if c.workDir == "" {
+		dir, err := ioutil.TempDir("", "compresspdf")
+		if err != nil {
+			return nil, err
+		}
+		c.workDir = dir
+	}
+
+	return c, nil
+}
+
+func (c *Compressor) logf(format string, a ...interface{}) {
+	if !c.verbose {
+		return
+	}
+	c.outMu.Lock()
+	defer c.outMu.Unlock()
+	fmt.Fprintln(c.logger, fmt.Sprintf(format, a...))
+}
+
+// Compress compresses the single pdf at path in place, unless it appears to
+// already have been compressed and WithForce wasn't given.
+func (c *Compressor) Compress(ctx context.Context, path string) (Result, error) {
+	return c.compressOne(ctx, path)
+}
+
+// CompressAll compresses every pdf found under paths.  Each entry may be a
+// plain pdf, a directory (walked recursively, see WithInclude/WithExclude),
+// or a .zip/.cbz/.tar.gz archive whose pdf members are compressed and the
+// archive rewritten.  Compression runs with up to WithJobs files in
+// flight at once; the first error encountered is returned, but results for
+// files that finished before it are still returned alongside it.
+func (c *Compressor) CompressAll(ctx context.Context, paths []string) ([]Result, error) {
+	var (
+		resMu   sync.Mutex
+		results []Result
+	)
+
+	p := pool.New(c.jobs, func(target string) error {
+		res, err := c.compressOne(ctx, target)
+		if err != nil {
+			return err
+		}
+		resMu.Lock()
+		results = append(results, res)
+		resMu.Unlock()
+		return nil
+	})
+
+	var sources []targetSource
+	var firstErr error
+	for _, t := range paths {
+		src, err := newTargetSource(t, c.include, c.exclude)
+		if err != nil {
+			firstErr = err
+			break
+		}
+		discovered, err := src.discover(c.workDir)
+		if err != nil {
+			firstErr = errors.Wrapf(err, "Discovering pdfs in %q", t)
+			break
+		}
+		sources = append(sources, src)
+		for _, pdfPath := range discovered {
+			p.Enqueue(pdfPath)
+		}
+	}
+
+	poolErr := p.Close()
+	if firstErr == nil {
+		firstErr = poolErr
+	}
+	if firstErr != nil {
+		return results, firstErr
+	}
+
+	for _, src := range sources {
+		if err := src.finish(); err != nil {
+			return results, err
+		}
+	}
+
+	return results, nil
+}
+
+func (c *Compressor) compressOne(ctx context.Context, target string) (Result, error) {
+	res := Result{Path: target}
+
+	if !c.force {
+		skip, err := c.appearsCompressed(ctx, target)
+		if err != nil {
+			return res, err
+		}
+		if skip {
+			c.logf("Skipping %s as it appears to be already-compressed", target)
+			res.Skipped = "already compressed"
+			if fi, statErr := os.Stat(target); statErr == nil {
+				res.OldSize, res.NewSize = fi.Size(), fi.Size()
+			}
+			return res, nil
+		}
+	}
+
+	return c.compress(ctx, target)
+}
+
+func (c *Compressor) appearsCompressed(ctx context.Context, target string) (bool, error) {
+	cmd := exec.CommandContext(ctx, c.pdfinfo, target)
+	b, err := cmd.CombinedOutput()
+	if err != nil {
+		return false, errors.Wrapf(err, "Running %q returned %q", cmd.Args, string(b))
+	}
+	scanner := bufio.NewScanner(strings.NewReader(string(b)))
+	for scanner.Scan() {
+		line := scanner.Text()
+		tokens := strings.SplitN(line, ":", 2)
+		if len(tokens) != 2 {
+			return false, errors.Errorf("Unexpected line of output %q in \n%s\n, when running %q", line, string(b), cmd.Args)
+		}
+		key := strings.TrimSpace(tokens[0])
+		value := tokens[1]
+		if key == "Producer" && strings.Contains(value, "Ghostscript") {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// pageCount runs pdfinfo on target and returns the "Pages" field, so
+// WithVerify can confirm a compression didn't drop any pages.
+func (c *Compressor) pageCount(ctx context.Context, target string) (int, error) {
+	cmd := exec.CommandContext(ctx, c.pdfinfo, target)
+	b, err := cmd.CombinedOutput()
+	if err != nil {
+		return 0, errors.Wrapf(err, "Running %q returned %q", cmd.Args, string(b))
+	}
+	scanner := bufio.NewScanner(strings.NewReader(string(b)))
+	for scanner.Scan() {
+		tokens := strings.SplitN(scanner.Text(), ":", 2)
+		if len(tokens) != 2 {
+			continue
+		}
+		if strings.TrimSpace(tokens[0]) != "Pages" {
+			continue
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(tokens[1]))
+		if err != nil {
+			return 0, errors.Wrapf(err, "Parsing page count from %q", scanner.Text())
+		}
+		return n, nil
+	}
+	return 0, errors.Errorf("No Pages line in pdfinfo output for %q", target)
+}
+
+func (c *Compressor) compress(ctx context.Context, target string) (Result, error) {
+	res := Result{Path: target}
+
+	tmpfile := gsOutputPath(c.workDir, target)
+
+	args := buildGsArgs(gsOptions{
+		Preset:        c.preset,
+		Compat:        c.compat,
+		ImageDPI:      c.imageDPI,
+		ColorStrategy: c.colorStrategy,
+		JpegQuality:   c.jpegQuality,
+		Input:         target,
+		Output:        tmpfile,
+	})
+
+	cmd := exec.CommandContext(ctx, c.gs, args...)
+	out, err := cmd.CombinedOutput()
+	res.GSStderr = string(out)
+	if err != nil {
+		return res, errors.Wrapf(err, "Running %q returned %q", cmd.Args, string(out))
+	}
+
+	oldFile, err := os.Stat(target)
+	if err != nil {
+		return res, errors.Wrapf(err, "Stating old file %q", target)
+	}
+	newFile, err := os.Stat(tmpfile)
+	if err != nil {
+		return res, errors.Wrapf(err, "Stating new file %q", tmpfile)
+	}
+	res.OldSize, res.NewSize = oldFile.Size(), newFile.Size()
+
+	growth := newFile.Size() - oldFile.Size()
+	if growth > 0 {
+		c.logf("Compressing %q made it grow from %s by %s; skipping.", target, humanize(oldFile.Size()), humanize(growth))
+		res.Skipped = "would have grown"
+		res.NewSize = oldFile.Size()
+		return res, nil
+	}
+
+	if c.verify {
+		oldPages, err := c.pageCount(ctx, target)
+		if err != nil {
+			return res, errors.Wrapf(err, "Checking page count of %q", target)
+		}
+		newPages, err := c.pageCount(ctx, tmpfile)
+		if err != nil {
+			return res, errors.Wrapf(err, "Checking page count of %q", tmpfile)
+		}
+		if newPages != oldPages {
+			return res, errors.Errorf("compressing %q produced %d pages, expected %d; refusing to replace it", target, newPages, oldPages)
+		}
+	}
+
+	if err := atomicReplace(target, tmpfile, c.backupDir, c.keepBackup); err != nil {
+		return res, errors.Wrapf(err, "Replacing %q with %q", target, tmpfile)
+	}
+	pct := percent(oldFile.Size(), newFile.Size())
+	c.logf("Shrank %q to %s, (%%%s of its original size)", target, humanize(newFile.Size()), pct)
+
+	return res, nil
+}
@@ -0,0 +1,127 @@
+package compresspdf
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// writeFakeGS writes a shell script standing in for gs: it finds the
+// -sOutputFile= argument and writes a short, valid-looking pdf to it, so
+// tests can drive the real Compressor.compress/CompressAll path through
+// exec.Command without depending on a real Ghostscript install.
+func writeFakeGS(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "gs")
+	script := `#!/bin/sh
+out=""
+for arg in "$@"; do
+  case "$arg" in
+    -sOutputFile=*) out="${arg#-sOutputFile=}" ;;
+  esac
+done
+printf '%%PDF-1.4 compressed' > "$out"
+`
+	if err := os.WriteFile(path, []byte(script), 0755); err != nil {
+		t.Fatalf("writing fake gs: %v", err)
+	}
+	return path
+}
+
+// writeFakePdfinfo writes a shell script standing in for pdfinfo that always
+// reports a non-Ghostscript Producer and a single page, so appearsCompressed
+// never skips and WithVerify's page-count check is satisfied.
+func writeFakePdfinfo(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "pdfinfo")
+	script := `#!/bin/sh
+echo "Producer: faketool"
+echo "Pages: 1"
+`
+	if err := os.WriteFile(path, []byte(script), 0755); err != nil {
+		t.Fatalf("writing fake pdfinfo: %v", err)
+	}
+	return path
+}
+
+func TestCompressAllWithFakeGS(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake gs/pdfinfo are shell scripts")
+	}
+	gs := writeFakeGS(t)
+	pdfinfo := writeFakePdfinfo(t)
+
+	dir := t.TempDir()
+	var paths []string
+	for i := 0; i < 4; i++ {
+		p := filepath.Join(dir, fmt.Sprintf("doc%d.pdf", i))
+		if err := os.WriteFile(p, []byte("%PDF-1.4 some much longer original content to shrink"), 0644); err != nil {
+			t.Fatalf("writing %q: %v", p, err)
+		}
+		paths = append(paths, p)
+	}
+
+	comp, err := New(
+		WithGhostscriptPath(gs),
+		WithPdfinfoPath(pdfinfo),
+		WithJobs(2),
+		WithWorkDir(t.TempDir()),
+	)
+	if err != nil {
+		t.Fatalf("New() = %v", err)
+	}
+
+	results, err := comp.CompressAll(context.Background(), paths)
+	if err != nil {
+		t.Fatalf("CompressAll() = %v", err)
+	}
+	if len(results) != len(paths) {
+		t.Fatalf("got %d results, want %d", len(results), len(paths))
+	}
+	for _, res := range results {
+		if res.Skipped != "" {
+			t.Errorf("%s: Skipped = %q, want it compressed", res.Path, res.Skipped)
+		}
+		if res.NewSize >= res.OldSize {
+			t.Errorf("%s: NewSize %d >= OldSize %d, want it to shrink", res.Path, res.NewSize, res.OldSize)
+		}
+	}
+}
+
+func TestCompressAllPropagatesGSError(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake gs/pdfinfo are shell scripts")
+	}
+	gsPath := filepath.Join(t.TempDir(), "gs")
+	if err := os.WriteFile(gsPath, []byte("#!/bin/sh\necho boom 1>&2\nexit 1\n"), 0755); err != nil {
+		t.Fatalf("writing fake gs: %v", err)
+	}
+	pdfinfo := writeFakePdfinfo(t)
+
+	target := filepath.Join(t.TempDir(), "doc.pdf")
+	if err := os.WriteFile(target, []byte("%PDF-1.4 x"), 0644); err != nil {
+		t.Fatalf("writing %q: %v", target, err)
+	}
+
+	comp, err := New(
+		WithGhostscriptPath(gsPath),
+		WithPdfinfoPath(pdfinfo),
+		WithJobs(2),
+		WithWorkDir(t.TempDir()),
+	)
+	if err != nil {
+		t.Fatalf("New() = %v", err)
+	}
+
+	_, err = comp.CompressAll(context.Background(), []string{target})
+	if err == nil {
+		t.Fatal("CompressAll() = nil, want an error from the failing gs invocation")
+	}
+	if !strings.Contains(err.Error(), "boom") {
+		t.Errorf("CompressAll() error = %v, want it to mention gs's stderr", err)
+	}
+}
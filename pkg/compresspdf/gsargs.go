@@ -0,0 +1,93 @@
+package compresspdf
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// validPresets are the PDFSETTINGS values gs understands.
+var validPresets = map[string]bool{
+	"screen":   true,
+	"ebook":    true,
+	"printer":  true,
+	"prepress": true,
+	"default":  true,
+}
+
+// validColorStrategies are the -sColorConversionStrategy values gs
+// understands.
+var validColorStrategies = map[string]bool{
+	"":                    true,
+	"leaveColorUnchanged": true,
+	"Gray":                true,
+	"RGB":                 true,
+	"CMYK":                true,
+}
+
+// ValidatePreset returns an error if preset isn't a gs PDFSETTINGS value
+// WithPreset understands.
+func ValidatePreset(preset string) error {
+	if !validPresets[preset] {
+		return errors.Errorf("invalid preset %q", preset)
+	}
+	return nil
+}
+
+// ValidateColorStrategy returns an error if strategy isn't a gs
+// ColorConversionStrategy value WithColorStrategy understands.
+func ValidateColorStrategy(strategy string) error {
+	if !validColorStrategies[strategy] {
+		return errors.Errorf("invalid color strategy %q", strategy)
+	}
+	return nil
+}
+
+// gsOptions captures everything buildGsArgs needs to build a gs argv for a
+// single compression, so the flag surface can be tested without invoking gs.
+type gsOptions struct {
+	Preset        string
+	Compat        string
+	ImageDPI      int
+	ColorStrategy string
+	JpegQuality   int
+	Input         string
+	Output        string
+}
+
+// buildGsArgs turns opts into the argv (excluding the gs binary itself)
+// passed to exec.Command.
+func buildGsArgs(opts gsOptions) []string {
+	args := []string{
+		fmt.Sprintf("-dPDFSETTINGS=/%s", opts.Preset),
+		fmt.Sprintf("-sOutputFile=%s", opts.Output),
+		"-sDEVICE=pdfwrite",
+		fmt.Sprintf("-dCompatibilityLevel=%s", opts.Compat),
+		"-dNOPAUSE",
+		"-dQUIET",
+		"-dBATCH",
+	}
+
+	if opts.ImageDPI > 0 {
+		args = append(args,
+			"-dDownsampleColorImages=true",
+			"-dDownsampleGrayImages=true",
+			"-dDownsampleMonoImages=true",
+			fmt.Sprintf("-dColorImageResolution=%d", opts.ImageDPI),
+			fmt.Sprintf("-dGrayImageResolution=%d", opts.ImageDPI),
+			fmt.Sprintf("-dMonoImageResolution=%d", opts.ImageDPI),
+		)
+	}
+	if opts.ColorStrategy != "" {
+		args = append(args, fmt.Sprintf("-sColorConversionStrategy=%s", opts.ColorStrategy))
+	}
+	if opts.JpegQuality > 0 {
+		args = append(args,
+			"-dEncodeColorImages=true",
+			fmt.Sprintf("-dJPEGQ=%d", opts.JpegQuality),
+		)
+	}
+
+	args = append(args, opts.Input)
+	return args
+}
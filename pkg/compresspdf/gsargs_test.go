@@ -0,0 +1,95 @@
+package compresspdf
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBuildGsArgs(t *testing.T) {
+	cases := []struct {
+		name string
+		opts gsOptions
+		want []string
+	}{
+		{
+			name: "defaults",
+			opts: gsOptions{Preset: "screen", Compat: "1.4", Input: "in.pdf", Output: "out.pdf"},
+			want: []string{
+				"-dPDFSETTINGS=/screen",
+				"-sOutputFile=out.pdf",
+				"-sDEVICE=pdfwrite",
+				"-dCompatibilityLevel=1.4",
+				"-dNOPAUSE",
+				"-dQUIET",
+				"-dBATCH",
+				"in.pdf",
+			},
+		},
+		{
+			name: "image dpi downsamples color, gray and mono",
+			opts: gsOptions{Preset: "ebook", Compat: "1.5", ImageDPI: 150, Input: "in.pdf", Output: "out.pdf"},
+			want: []string{
+				"-dPDFSETTINGS=/ebook",
+				"-sOutputFile=out.pdf",
+				"-sDEVICE=pdfwrite",
+				"-dCompatibilityLevel=1.5",
+				"-dNOPAUSE",
+				"-dQUIET",
+				"-dBATCH",
+				"-dDownsampleColorImages=true",
+				"-dDownsampleGrayImages=true",
+				"-dDownsampleMonoImages=true",
+				"-dColorImageResolution=150",
+				"-dGrayImageResolution=150",
+				"-dMonoImageResolution=150",
+				"in.pdf",
+			},
+		},
+		{
+			name: "color strategy and jpeg quality",
+			opts: gsOptions{Preset: "printer", Compat: "1.4", ColorStrategy: "Gray", JpegQuality: 80, Input: "in.pdf", Output: "out.pdf"},
+			want: []string{
+				"-dPDFSETTINGS=/printer",
+				"-sOutputFile=out.pdf",
+				"-sDEVICE=pdfwrite",
+				"-dCompatibilityLevel=1.4",
+				"-dNOPAUSE",
+				"-dQUIET",
+				"-dBATCH",
+				"-sColorConversionStrategy=Gray",
+				"-dEncodeColorImages=true",
+				"-dJPEGQ=80",
+				"in.pdf",
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := buildGsArgs(c.opts)
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("buildGsArgs(%+v) =\n%v, want\n%v", c.opts, got, c.want)
+			}
+		})
+	}
+}
+
+func TestValidatePreset(t *testing.T) {
+	if err := ValidatePreset("ebook"); err != nil {
+		t.Errorf("ValidatePreset(%q) = %v, want nil", "ebook", err)
+	}
+	if err := ValidatePreset("bogus"); err == nil {
+		t.Error("ValidatePreset(\"bogus\") = nil, want an error")
+	}
+}
+
+func TestValidateColorStrategy(t *testing.T) {
+	for _, ok := range []string{"", "RGB", "Gray", "CMYK", "leaveColorUnchanged"} {
+		if err := ValidateColorStrategy(ok); err != nil {
+			t.Errorf("ValidateColorStrategy(%q) = %v, want nil", ok, err)
+		}
+	}
+	if err := ValidateColorStrategy("bogus"); err == nil {
+		t.Error("ValidateColorStrategy(\"bogus\") = nil, want an error")
+	}
+}
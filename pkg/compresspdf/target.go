@@ -0,0 +1,327 @@
+package compresspdf
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// targetSource discovers the individual pdf files that a single
+// command-line argument expands to, and knows how to put any compressed
+// results back where they belong.  Implementations exist for plain files,
+// directories (walked recursively) and archives (zip/cbz/tar.gz), so new
+// container types can be plugged in later.
+type targetSource interface {
+	// discover returns the paths (on-disk, possibly extracted under
+	// workDir) of every pdf this target expands to.  Each path is fed to
+	// Compressor.compressOne exactly as a plain-file target would be.
+	discover(workDir string) ([]string, error)
+	// finish is called once every path returned by discover has been run
+	// through compressOne, so archive implementations can rewrite their
+	// container with whichever members ended up smaller.
+	finish() error
+}
+
+// newTargetSource picks the targetSource implementation for arg based on
+// whether it is a directory, a supported archive, or a plain file.
+func newTargetSource(arg, include, exclude string) (targetSource, error) {
+	info, err := os.Stat(arg)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Stating %q", arg)
+	}
+	if info.IsDir() {
+		return &directory{root: arg, include: include, exclude: exclude}, nil
+	}
+	switch {
+	case strings.HasSuffix(arg, ".zip"), strings.HasSuffix(arg, ".cbz"):
+		return &zipArchive{path: arg}, nil
+	case strings.HasSuffix(arg, ".tar.gz"), strings.HasSuffix(arg, ".tgz"):
+		return &tarGzArchive{path: arg}, nil
+	default:
+		return plainFile{path: arg}, nil
+	}
+}
+
+// plainFile is a targetSource for a single pdf passed directly on the
+// command line.
+type plainFile struct {
+	path string
+}
+
+func (f plainFile) discover(workDir string) ([]string, error) { return []string{f.path}, nil }
+func (f plainFile) finish() error                             { return nil }
+
+// directory is a targetSource that walks root looking for pdfs, optionally
+// filtered by include/exclude globs matched against the base name.
+type directory struct {
+	root             string
+	include, exclude string
+}
+
+func (d *directory) discover(workDir string) ([]string, error) {
+	var out []string
+	err := filepath.WalkDir(d.root, func(p string, de fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if de.IsDir() {
+			return nil
+		}
+		if !strings.EqualFold(filepath.Ext(p), ".pdf") {
+			return nil
+		}
+		name := de.Name()
+		if d.include != "" {
+			ok, err := filepath.Match(d.include, name)
+			if err != nil {
+				return errors.Wrapf(err, "Matching --include %q", d.include)
+			}
+			if !ok {
+				return nil
+			}
+		}
+		if d.exclude != "" {
+			ok, err := filepath.Match(d.exclude, name)
+			if err != nil {
+				return errors.Wrapf(err, "Matching --exclude %q", d.exclude)
+			}
+			if ok {
+				return nil
+			}
+		}
+		out = append(out, p)
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "Walking %q", d.root)
+	}
+	return out, nil
+}
+
+func (d *directory) finish() error { return nil }
+
+// archiveMember is one entry of a zip or tar.gz archive.  pdf members are
+// extracted to disk so they can be compressed in place; everything else is
+// kept in memory and copied back verbatim.  For tar archives only regular
+// files, symlinks and hard links are kept; directory entries and other
+// special entries are not written back.
+type archiveMember struct {
+	name    string
+	mode    os.FileMode
+	isPDF   bool
+	tmpPath string
+	data    []byte
+
+	// typeflag and linkname are only set for tar members that aren't
+	// plain regular files, e.g. tar.TypeSymlink/tar.TypeLink, so finish
+	// can round-trip them instead of silently dropping them.
+	typeflag byte
+	linkname string
+}
+
+// zipArchive is a targetSource backed by a .zip or .cbz file.
+type zipArchive struct {
+	path    string
+	members []archiveMember
+}
+
+func (z *zipArchive) discover(workDir string) ([]string, error) {
+	r, err := zip.OpenReader(z.path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Opening zip %q", z.path)
+	}
+	defer r.Close()
+
+	var pdfPaths []string
+	for _, f := range r.File {
+		if err := func() error {
+			rc, err := f.Open()
+			if err != nil {
+				return errors.Wrapf(err, "Opening %q in %q", f.Name, z.path)
+			}
+			defer rc.Close()
+
+			if strings.EqualFold(filepath.Ext(f.Name), ".pdf") {
+				tmpPath := archiveMemberTmpPath(workDir, z.path, f.Name)
+				out, err := os.Create(tmpPath)
+				if err != nil {
+					return err
+				}
+				defer out.Close()
+				if _, err := io.Copy(out, rc); err != nil {
+					return err
+				}
+				z.members = append(z.members, archiveMember{name: f.Name, mode: f.Mode(), isPDF: true, tmpPath: tmpPath})
+				pdfPaths = append(pdfPaths, tmpPath)
+				return nil
+			}
+
+			b, err := io.ReadAll(rc)
+			if err != nil {
+				return err
+			}
+			z.members = append(z.members, archiveMember{name: f.Name, mode: f.Mode(), data: b})
+			return nil
+		}(); err != nil {
+			return nil, err
+		}
+	}
+	return pdfPaths, nil
+}
+
+func (z *zipArchive) finish() error {
+	tmpPath := z.path + ".tmp"
+	out, err := os.Create(tmpPath)
+	if err != nil {
+		return errors.Wrapf(err, "Creating %q", tmpPath)
+	}
+	w := zip.NewWriter(out)
+	for _, m := range z.members {
+		hdr := &zip.FileHeader{Name: m.name, Method: zip.Deflate}
+		hdr.SetMode(m.mode)
+		fw, err := w.CreateHeader(hdr)
+		if err != nil {
+			return err
+		}
+		data := m.data
+		if m.isPDF {
+			if data, err = os.ReadFile(m.tmpPath); err != nil {
+				return errors.Wrapf(err, "Reading compressed member %q", m.name)
+			}
+		}
+		if _, err := fw.Write(data); err != nil {
+			return errors.Wrapf(err, "Writing member %q to %q", m.name, tmpPath)
+		}
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, z.path)
+}
+
+// tarGzArchive is a targetSource backed by a .tar.gz or .tgz file.
+type tarGzArchive struct {
+	path    string
+	members []archiveMember
+}
+
+func (t *tarGzArchive) discover(workDir string) ([]string, error) {
+	f, err := os.Open(t.path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Opening %q", t.path)
+	}
+	defer f.Close()
+	gzr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Reading gzip header of %q", t.path)
+	}
+	defer gzr.Close()
+
+	var pdfPaths []string
+	tr := tar.NewReader(gzr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "Reading %q", t.path)
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeSymlink, tar.TypeLink:
+			t.members = append(t.members, archiveMember{
+				name:     hdr.Name,
+				mode:     hdr.FileInfo().Mode(),
+				typeflag: hdr.Typeflag,
+				linkname: hdr.Linkname,
+			})
+			continue
+		case tar.TypeReg:
+			// handled below
+		default:
+			continue
+		}
+
+		if strings.EqualFold(filepath.Ext(hdr.Name), ".pdf") {
+			tmpPath := archiveMemberTmpPath(workDir, t.path, hdr.Name)
+			out, err := os.Create(tmpPath)
+			if err != nil {
+				return nil, err
+			}
+			if _, err := io.Copy(out, tr); err != nil {
+				out.Close()
+				return nil, err
+			}
+			out.Close()
+			t.members = append(t.members, archiveMember{name: hdr.Name, mode: hdr.FileInfo().Mode(), isPDF: true, tmpPath: tmpPath})
+			pdfPaths = append(pdfPaths, tmpPath)
+			continue
+		}
+
+		b, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, err
+		}
+		t.members = append(t.members, archiveMember{name: hdr.Name, mode: hdr.FileInfo().Mode(), data: b})
+	}
+	return pdfPaths, nil
+}
+
+func (t *tarGzArchive) finish() error {
+	tmpPath := t.path + ".tmp"
+	out, err := os.Create(tmpPath)
+	if err != nil {
+		return errors.Wrapf(err, "Creating %q", tmpPath)
+	}
+	gzw := gzip.NewWriter(out)
+	tw := tar.NewWriter(gzw)
+	for _, m := range t.members {
+		if m.typeflag == tar.TypeSymlink || m.typeflag == tar.TypeLink {
+			hdr := &tar.Header{
+				Typeflag: m.typeflag,
+				Name:     m.name,
+				Linkname: m.linkname,
+				Mode:     int64(m.mode.Perm()),
+			}
+			if err := tw.WriteHeader(hdr); err != nil {
+				return err
+			}
+			continue
+		}
+
+		data := m.data
+		if m.isPDF {
+			if data, err = os.ReadFile(m.tmpPath); err != nil {
+				return errors.Wrapf(err, "Reading compressed member %q", m.name)
+			}
+		}
+		hdr := &tar.Header{Name: m.name, Mode: int64(m.mode.Perm()), Size: int64(len(data))}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := tw.Write(data); err != nil {
+			return errors.Wrapf(err, "Writing member %q to %q", m.name, tmpPath)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := gzw.Close(); err != nil {
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, t.path)
+}
@@ -0,0 +1,182 @@
+package compresspdf
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"github.com/pkg/errors"
+)
+
+// gsOutputPath returns the path gs should write target's compressed output
+// to: a name under workDir derived from target's full path rather than just
+// its basename, so two different targets that happen to share a basename
+// (e.g. two pdfs called scan.pdf under different directories, or an archive
+// member already staged under workDir) never collide.
+func gsOutputPath(workDir, target string) string {
+	h := fnv.New64a()
+	io.WriteString(h, target)
+	return filepath.Join(workDir, fmt.Sprintf("%x-%s.out", h.Sum64(), filepath.Base(target)))
+}
+
+// archiveMemberTmpPath returns the path a pdf member of archivePath named
+// memberName should be extracted to under workDir.  The name is derived
+// from both the archive path and the member name, the same way
+// gsOutputPath hashes a target's full path, so two different archives
+// discovered into the same workDir (e.g. two archives each containing a
+// "doc.pdf" as their first pdf member) never extract to the same path.
+func archiveMemberTmpPath(workDir, archivePath, memberName string) string {
+	h := fnv.New64a()
+	io.WriteString(h, archivePath)
+	io.WriteString(h, "\x00")
+	io.WriteString(h, memberName)
+	return filepath.Join(workDir, fmt.Sprintf("%x-%s", h.Sum64(), filepath.Base(memberName)))
+}
+
+// hashFile returns the SHA-256 of path's contents, read fresh from disk.
+func hashFile(path string) ([sha256.Size]byte, error) {
+	var sum [sha256.Size]byte
+	f, err := os.Open(path)
+	if err != nil {
+		return sum, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return sum, err
+	}
+	copy(sum[:], h.Sum(nil))
+	return sum, nil
+}
+
+// atomicReplace swaps newFile in for original: it verifies newFile's bytes
+// are stable on disk, moves original aside to a .bak (under backupDir if
+// set), moves newFile onto original, then re-hashes the result to confirm
+// the swap landed intact.  The .bak is removed unless keepBackup is set.
+func atomicReplace(original, newFile, backupDir string, keepBackup bool) error {
+	sum, err := hashFile(newFile)
+	if err != nil {
+		return errors.Wrapf(err, "Hashing %q", newFile)
+	}
+	if confirm, err := hashFile(newFile); err != nil {
+		return errors.Wrapf(err, "Re-hashing %q", newFile)
+	} else if confirm != sum {
+		return errors.Errorf("%q changed on disk while being verified", newFile)
+	}
+
+	backupPath := original + ".bak"
+	if backupDir != "" {
+		if err := os.MkdirAll(backupDir, 0777); err != nil {
+			return errors.Wrapf(err, "Creating --backup-dir %q", backupDir)
+		}
+		backupPath = filepath.Join(backupDir, filepath.Base(original)+".bak")
+	}
+	if err := renameOrCopy(original, backupPath); err != nil {
+		return errors.Wrapf(err, "Backing up %q to %q", original, backupPath)
+	}
+
+	if err := renameOrCopy(newFile, original); err != nil {
+		if restoreErr := renameOrCopy(backupPath, original); restoreErr != nil {
+			return errors.Wrapf(err, "Replacing %q (and restoring its backup also failed: %v)", original, restoreErr)
+		}
+		return errors.Wrapf(err, "Replacing %q", original)
+	}
+
+	confirm, err := hashFile(original)
+	if err != nil {
+		return errors.Wrapf(err, "Hashing replaced %q", original)
+	}
+	if confirm != sum {
+		return errors.Errorf("%q didn't match its expected hash after being replaced", original)
+	}
+
+	if !keepBackup {
+		if err := os.Remove(backupPath); err != nil {
+			return errors.Wrapf(err, "Removing backup %q", backupPath)
+		}
+	}
+	return nil
+}
+
+// renameOrCopy renames src onto dst, falling back to a copy+fsync+rename
+// dance when the two paths are on different filesystems.
+func renameOrCopy(src, dst string) error {
+	err := os.Rename(src, dst)
+	if err == nil {
+		return nil
+	}
+	linkErr, ok := err.(*os.LinkError)
+	if !ok || linkErr.Err != syscall.EXDEV {
+		return err
+	}
+
+	tmp := dst + ".tmp"
+	if err := copyFile(src, tmp); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, dst); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Remove(src)
+}
+
+func copyFile(src, dst string) (err error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return
+	}
+	defer func() {
+		cerr := out.Close()
+		if err == nil {
+			err = cerr
+		}
+	}()
+	if _, err = io.Copy(out, in); err != nil {
+		return
+	}
+	err = out.Sync()
+	return
+}
+
+var suffixes = []string{
+	"b",
+	"K",
+	"M",
+	"G",
+}
+
+func percent(old, new int64) string {
+	f := 100.0 * float64(new) / float64(old)
+	switch {
+	case f < 1:
+		return fmt.Sprintf("%.2f", f)
+	case f < 10:
+		return fmt.Sprintf("%.1f", f)
+	default:
+		return fmt.Sprintf("%.0f", f)
+	}
+}
+
+func humanize(i int64) string {
+	f := float64(i)
+	s := suffixes[len(suffixes)-1]
+	for _, candidate := range suffixes {
+		if f < 1024 {
+			s = candidate
+			break
+		}
+		f = f / 1024
+	}
+	return fmt.Sprintf("%.1f%s", f, s)
+}
@@ -0,0 +1,84 @@
+// Package pool provides a small bounded worker pool for running
+// file-oriented jobs concurrently.
+package pool
+
+import (
+	"sync"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// Job is the unit of work a FileWorkerPool runs for each enqueued target.
+type Job func(target string) error
+
+// FileWorkerPool runs a fixed number of worker goroutines, each pulling
+// targets off a shared channel and invoking a Job on them.  The first error
+// returned by any Job is captured and returned from Close.  Workers keep
+// draining the channel after a Job errors, rather than exiting, so Enqueue
+// never blocks forever even if every in-flight Job has failed.
+type FileWorkerPool struct {
+	jobs  chan string
+	group *errgroup.Group
+
+	mu  sync.Mutex
+	err error
+}
+
+// New creates a FileWorkerPool with the given concurrency (clamped to at
+// least 1) that invokes job for every target passed to Enqueue.
+func New(concurrency int, job Job) *FileWorkerPool {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	p := &FileWorkerPool{
+		jobs: make(chan string),
+	}
+	var g errgroup.Group
+	p.group = &g
+	for i := 0; i < concurrency; i++ {
+		g.Go(func() error {
+			for target := range p.jobs {
+				if p.failed() {
+					continue
+				}
+				if err := job(target); err != nil {
+					p.fail(err)
+				}
+			}
+			return nil
+		})
+	}
+	return p
+}
+
+func (p *FileWorkerPool) fail(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.err == nil {
+		p.err = err
+	}
+}
+
+func (p *FileWorkerPool) failed() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.err != nil
+}
+
+// Enqueue adds target to the pool's work queue, blocking until a worker is
+// free to accept it.
+func (p *FileWorkerPool) Enqueue(target string) {
+	p.jobs <- target
+}
+
+// Close stops accepting new work, waits for all workers to finish, and
+// returns the first error encountered by any Job, if any.
+func (p *FileWorkerPool) Close() error {
+	close(p.jobs)
+	if err := p.group.Wait(); err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.err
+}
@@ -0,0 +1,76 @@
+package pool
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFileWorkerPoolRunsAllJobs(t *testing.T) {
+	var mu sync.Mutex
+	var seen []string
+
+	p := New(2, func(target string) error {
+		mu.Lock()
+		seen = append(seen, target)
+		mu.Unlock()
+		return nil
+	})
+	for i := 0; i < 5; i++ {
+		p.Enqueue(fmt.Sprintf("file-%d.pdf", i))
+	}
+	if err := p.Close(); err != nil {
+		t.Fatalf("Close() = %v, want nil", err)
+	}
+	if len(seen) != 5 {
+		t.Fatalf("ran %d jobs, want 5", len(seen))
+	}
+}
+
+func TestFileWorkerPoolPropagatesError(t *testing.T) {
+	wantErr := fmt.Errorf("boom")
+
+	p := New(2, func(target string) error {
+		if target == "bad.pdf" {
+			return wantErr
+		}
+		return nil
+	})
+	p.Enqueue("good.pdf")
+	p.Enqueue("bad.pdf")
+	p.Enqueue("good.pdf")
+	if err := p.Close(); err != wantErr {
+		t.Fatalf("Close() = %v, want %v", err, wantErr)
+	}
+}
+
+// TestFileWorkerPoolDoesNotDeadlockOnErrors guards against a regression
+// where a worker that hit a Job error stopped draining jobs: once every
+// worker had errored, Enqueue would block forever because nothing was left
+// to receive from the channel.
+func TestFileWorkerPoolDoesNotDeadlockOnErrors(t *testing.T) {
+	const concurrency = 2
+	const jobCount = 10
+
+	p := New(concurrency, func(target string) error {
+		return fmt.Errorf("always fails: %s", target)
+	})
+
+	done := make(chan error, 1)
+	go func() {
+		for i := 0; i < jobCount; i++ {
+			p.Enqueue(fmt.Sprintf("file-%d.pdf", i))
+		}
+		done <- p.Close()
+	}()
+
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatal("Close() = nil, want an error from the failing jobs")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("pool deadlocked enqueuing jobs after every worker had errored")
+	}
+}